@@ -44,6 +44,17 @@ type Options struct {
 	HostPublicKeys []string
 	Logger         logger      // required
 	TLSConfig      *tls.Config // (optional)
+
+	// SessionType tags the connection with one of the SessionType*
+	// constants (e.g. SessionTypeVSCode, SessionTypeSCP). It is
+	// reported to the Live Share host and used locally to break down
+	// Session.Stats() by kind of client. (optional)
+	SessionType string
+
+	// MaxRetryDuration bounds how long ConnectResilient keeps
+	// retrying a lost connection before giving up and reporting
+	// StateFailed. Zero means retry indefinitely. Unused by Connect.
+	MaxRetryDuration time.Duration
 }
 
 // uri returns a websocket URL for the specified options.
@@ -79,6 +90,27 @@ func (opts *Options) uri(action string) (string, error) {
 // options, and returns a session representing the connection.
 // The caller must call the session's Close method to end the session.
 func Connect(ctx context.Context, opts Options) (*Session, error) {
+	s, err := connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	go s.heartbeat(ctx, 1*time.Minute)
+
+	// Connect to the gRPC server so we can make requests anywhere we have access to the session
+	if err := s.connectToGrpcServer(ctx, opts.SessionToken); err != nil {
+		return nil, fmt.Errorf("error connecting to internal server: %w", err)
+	}
+
+	return s, nil
+}
+
+// connect establishes the websocket, SSH, and RPC layers and joins
+// the workspace, without starting the session's heartbeat or gRPC
+// tunnel. It's the shared basis for both Connect and the in-place
+// reconnection done by ConnectResilient, which needs a fresh
+// transport without a second heartbeat goroutine racing the
+// original session's.
+func connect(ctx context.Context, opts Options) (*Session, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Connect")
 	defer span.Finish()
 
@@ -109,6 +141,7 @@ func Connect(ctx context.Context, opts Options) (*Session, error) {
 		JoiningUserSessionToken: opts.SessionToken,
 		ClientCapabilities: clientCapabilities{
 			IsNonInteractive: false,
+			SessionType:      opts.SessionType,
 		},
 	}
 	var result joinWorkspaceResult
@@ -121,17 +154,11 @@ func Connect(ctx context.Context, opts Options) (*Session, error) {
 		rpc:             rpc,
 		grpc:            grpc.NewClient(),
 		clientName:      opts.ClientName,
+		sessionType:     opts.SessionType,
 		keepAliveReason: make(chan string, 1),
 		logger:          opts.Logger,
+		relay:           viaRelay(opts.RelayEndpoint),
 	}
-	go s.heartbeat(ctx, 1*time.Minute)
-
-	// Connect to the gRPC server so we can make requests anywhere we have access to the session
-	err = s.connectToGrpcServer(ctx, opts.SessionToken)
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to internal server: %w", err)
-	}
-
 	return s, nil
 }
 
@@ -151,7 +178,8 @@ func (s *Session) connectToGrpcServer(ctx context.Context, token string) error {
 	}()
 
 	// Make a connection to the gRPC server
-	err = s.grpc.Connect(ctx, listen, localGrpcServerPort, token)
+	_, _, grpcClient := s.transport()
+	err = grpcClient.Connect(ctx, listen, localGrpcServerPort, token)
 
 	if err != nil {
 		return fmt.Errorf("failed to establish connection on port %d: %w", localGrpcServerPort, err)
@@ -166,7 +194,8 @@ func (s *Session) connectToGrpcServer(ctx context.Context, token string) error {
 }
 
 type clientCapabilities struct {
-	IsNonInteractive bool `json:"isNonInteractive"`
+	IsNonInteractive bool   `json:"isNonInteractive"`
+	SessionType      string `json:"sessionType,omitempty"`
 }
 
 type joinWorkspaceArgs struct {
@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// handshake is the first line a client writes after dialing the
+// daemon socket, identifying which codespace's Session it wants
+// subsequent JSON-RPC calls routed to.
+type handshake struct {
+	Codespace string `json:"codespace"`
+}
+
+// readHandshake reads conn one byte at a time up to the newline, so
+// that no byte of the JSON-RPC stream following the handshake is
+// consumed before conn is handed on.
+func readHandshake(conn net.Conn) (codespace string, err error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := conn.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading handshake: %w", err)
+		}
+	}
+	var h handshake
+	if err := json.Unmarshal(line, &h); err != nil {
+		return "", fmt.Errorf("error decoding handshake: %w", err)
+	}
+	if h.Codespace == "" {
+		return "", fmt.Errorf("handshake missing codespace name")
+	}
+	return h.Codespace, nil
+}
@@ -0,0 +1,247 @@
+// Package daemon runs a long-lived process that holds open Sessions
+// for multiple codespaces and serves them to ordinary gh invocations
+// over a per-user socket, so each `gh codespace` command doesn't pay
+// the cost of a fresh websocket + SSH + gRPC handshake.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cli/cli/v2/pkg/liveshare"
+	"github.com/cli/cli/v2/pkg/liveshare/ipc"
+)
+
+// AutoAddr is the pseudo-address callers pass to resolve to the
+// current user's default daemon socket, mirroring how "auto" resolves
+// in similar forwarding daemons.
+const AutoAddr = "auto"
+
+// SocketPath returns the path of the per-user daemon socket that
+// AutoAddr resolves to.
+func SocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "gh-codespaces")
+	} else {
+		dir = filepath.Join(dir, "gh-codespaces")
+	}
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "default"
+	}
+	return filepath.Join(dir, user+".sock"), nil
+}
+
+// ResolveAddr turns AutoAddr into a concrete socket path, passing any
+// other address through unchanged.
+func ResolveAddr(addr string) (string, error) {
+	if addr != AutoAddr {
+		return addr, nil
+	}
+	return SocketPath()
+}
+
+// A Daemon holds one Session per codespace name and serves JSON-RPC
+// requests against them to any number of concurrently connected
+// clients.
+type Daemon struct {
+	connect func(ctx context.Context, codespace string) (*liveshare.Session, error)
+	logger  interface {
+		Printf(f string, v ...interface{})
+	}
+	idleTimeout time.Duration
+	quietConns  bool
+
+	mu       sync.Mutex
+	sessions map[string]*liveshare.Session
+	inflight map[string]*sessionCall
+	clients  int
+	lastIdle time.Time
+}
+
+// sessionCall tracks a single in-flight d.connect call for a
+// codespace, so concurrent first-time callers share its result
+// instead of each starting their own connection.
+type sessionCall struct {
+	done    chan struct{}
+	session *liveshare.Session
+	err     error
+}
+
+// Options configures a Daemon.
+type Options struct {
+	// Connect is called the first time a client asks for a
+	// codespace the daemon doesn't already hold a Session for.
+	Connect func(ctx context.Context, codespace string) (*liveshare.Session, error)
+	Logger  interface {
+		Printf(f string, v ...interface{})
+	}
+	// IdleTimeout shuts the daemon down after this long with no
+	// connected clients. Zero disables idle shutdown.
+	IdleTimeout time.Duration
+	// QuietConnections suppresses the per-connection log lines
+	// (bad handshakes, connect errors, client disconnects), leaving
+	// only daemon lifecycle logging such as idle shutdown. Useful
+	// when the daemon is wrapped by something that already logs
+	// per-request activity of its own. (optional)
+	QuietConnections bool
+}
+
+// New returns a Daemon configured with opts.
+func New(opts Options) *Daemon {
+	return &Daemon{
+		connect:     opts.Connect,
+		logger:      opts.Logger,
+		idleTimeout: opts.IdleTimeout,
+		quietConns:  opts.QuietConnections,
+		sessions:    make(map[string]*liveshare.Session),
+		inflight:    make(map[string]*sessionCall),
+		lastIdle:    timeNow(),
+	}
+}
+
+// timeNow exists so idle-shutdown logic is easy to drive from tests
+// without depending on a real clock elsewhere in the package.
+var timeNow = time.Now
+
+// ListenAndServe listens on the daemon's Unix socket (creating its
+// parent directory if needed) and serves client connections until ctx
+// is canceled or the idle timeout fires.
+func (d *Daemon) ListenAndServe(ctx context.Context) error {
+	path, err := SocketPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating socket dir: %w", err)
+	}
+	os.Remove(path) // nolint:errcheck // stale socket from a previous run
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", path, err)
+	}
+	defer ln.Close()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	if d.idleTimeout > 0 {
+		go d.watchIdle(ctx, ln)
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				return err
+			}
+		}
+		d.mu.Lock()
+		d.clients++
+		d.mu.Unlock()
+
+		go d.handleConn(ctx, conn)
+	}
+}
+
+func (d *Daemon) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	defer func() {
+		d.mu.Lock()
+		d.clients--
+		d.lastIdle = timeNow()
+		d.mu.Unlock()
+	}()
+
+	codespace, err := readHandshake(conn)
+	if err != nil {
+		if d.logger != nil && !d.quietConns {
+			d.logger.Printf("daemon: bad handshake: %v", err)
+		}
+		return
+	}
+
+	session, err := d.sessionFor(ctx, codespace)
+	if err != nil {
+		if d.logger != nil && !d.quietConns {
+			d.logger.Printf("daemon: error connecting to %s: %v", codespace, err)
+		}
+		return
+	}
+
+	srv := ipc.NewServer(session)
+	if err := srv.ServeOne(ctx, conn); err != nil && d.logger != nil && !d.quietConns {
+		d.logger.Printf("daemon: client for %s disconnected: %v", codespace, err)
+	}
+}
+
+// sessionFor returns the daemon's Session for codespace, connecting
+// one (via d.connect) the first time it's requested. Concurrent
+// first-time callers for the same codespace share a single in-flight
+// d.connect call rather than each racing to store their own Session,
+// which would leak every loser's websocket/SSH/gRPC connections and
+// heartbeat goroutine.
+func (d *Daemon) sessionFor(ctx context.Context, codespace string) (*liveshare.Session, error) {
+	d.mu.Lock()
+	if s, ok := d.sessions[codespace]; ok {
+		d.mu.Unlock()
+		return s, nil
+	}
+	if call, ok := d.inflight[codespace]; ok {
+		d.mu.Unlock()
+		<-call.done
+		return call.session, call.err
+	}
+
+	call := &sessionCall{done: make(chan struct{})}
+	d.inflight[codespace] = call
+	d.mu.Unlock()
+
+	call.session, call.err = d.connect(ctx, codespace)
+
+	d.mu.Lock()
+	if call.err == nil {
+		d.sessions[codespace] = call.session
+	}
+	delete(d.inflight, codespace)
+	d.mu.Unlock()
+	close(call.done)
+
+	return call.session, call.err
+}
+
+// watchIdle closes ln, forcing ListenAndServe to return, once no
+// client has been connected for d.idleTimeout.
+func (d *Daemon) watchIdle(ctx context.Context, ln net.Listener) {
+	t := time.NewTicker(d.idleTimeout / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			d.mu.Lock()
+			idleFor := timeNow().Sub(d.lastIdle)
+			idle := d.clients == 0 && idleFor >= d.idleTimeout
+			d.mu.Unlock()
+			if idle {
+				if d.logger != nil {
+					d.logger.Printf("daemon: idle for %s, shutting down", idleFor)
+				}
+				ln.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cli/cli/v2/pkg/liveshare"
+)
+
+// TestSessionForDedupesConcurrentConnects asserts that two concurrent
+// first-time callers for the same codespace share a single Connect
+// call and the single *liveshare.Session it returns, rather than each
+// racing to store their own (which would leak the loser's Session).
+func TestSessionForDedupesConcurrentConnects(t *testing.T) {
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	d := New(Options{
+		Connect: func(ctx context.Context, codespace string) (*liveshare.Session, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+			}
+			<-release
+			return &liveshare.Session{}, nil
+		},
+	})
+
+	const callers = 2
+	results := make([]*liveshare.Session, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = d.sessionFor(context.Background(), "my-codespace")
+		}(i)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connect was never called")
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("Connect called %d times, want 1", got)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("sessionFor(%d): %v", i, err)
+		}
+	}
+	if results[0] == nil || results[0] != results[1] {
+		t.Errorf("sessionFor returned different sessions for concurrent callers: %v, %v", results[0], results[1])
+	}
+}
+
+// TestSessionForReusesCachedSession asserts that once a codespace's
+// Session is cached, a later call doesn't invoke Connect again.
+func TestSessionForReusesCachedSession(t *testing.T) {
+	var calls int32
+	want := &liveshare.Session{}
+
+	d := New(Options{
+		Connect: func(ctx context.Context, codespace string) (*liveshare.Session, error) {
+			atomic.AddInt32(&calls, 1)
+			return want, nil
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		got, err := d.sessionFor(context.Background(), "my-codespace")
+		if err != nil {
+			t.Fatalf("sessionFor: %v", err)
+		}
+		if got != want {
+			t.Errorf("sessionFor returned %v, want %v", got, want)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Connect called %d times, want 1", calls)
+	}
+}
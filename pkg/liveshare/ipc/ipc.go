@@ -0,0 +1,139 @@
+// Package ipc serves a Live Share Session over a line-delimited
+// JSON-RPC 2.0 interface, so editor extensions and other third-party
+// tools can drive a codespace without importing this module directly
+// or scripting gh invocations.
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/cli/cli/v2/pkg/liveshare"
+)
+
+// A Server serves JSON-RPC requests against a single Session. The
+// zero value is not usable; construct one with NewServer.
+type Server struct {
+	session *liveshare.Session
+
+	mu        sync.Mutex
+	listeners map[int]io.Closer // remote port -> bound local listener
+}
+
+// NewServer returns a Server that answers requests against session.
+func NewServer(session *liveshare.Session) *Server {
+	return &Server{
+		session:   session,
+		listeners: make(map[int]io.Closer),
+	}
+}
+
+// ServeStdio serves the protocol on r/w until r is exhausted. ctx is
+// passed to each method call; canceling it does not interrupt a read
+// that is waiting on r. It is meant to be called with os.Stdin/os.Stdout.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	return s.serve(ctx, r, w)
+}
+
+// ServeConn accepts connections on ln, serving each one as an
+// independent JSON-RPC stream, until ctx is canceled.
+func (s *Server) ServeConn(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				return err
+			}
+		}
+		go func() {
+			defer conn.Close()
+			s.serve(ctx, conn, conn) // nolint:errcheck
+		}()
+	}
+}
+
+// ServeOne serves the protocol synchronously on a single
+// already-accepted connection, returning once conn is exhausted;
+// canceling ctx does not by itself unblock a pending read on conn.
+// Unlike ServeConn, it neither spawns a background
+// goroutine for the protocol loop nor tries to Accept again
+// afterwards, so callers that already own a single net.Conn (e.g. a
+// daemon dispatching one accepted client) can serve it directly
+// without adapting it to a net.Listener.
+func (s *Server) ServeOne(ctx context.Context, conn net.Conn) error {
+	return s.serve(ctx, conn, conn)
+}
+
+// request is a subset of JSON-RPC 2.0 request objects; Params is
+// re-decoded per method once the method is known.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *Server) serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	var encMu sync.Mutex
+	notify := func(method string, params interface{}) {
+		encMu.Lock()
+		defer encMu.Unlock()
+		enc.Encode(notification{JSONRPC: "2.0", Method: "$/" + method, Params: params}) // nolint:errcheck
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue // malformed line; nothing sensible to reply to
+		}
+
+		resp := response{JSONRPC: "2.0", ID: req.ID}
+		result, err := s.dispatch(ctx, req.Method, req.Params, notify)
+		if err != nil {
+			resp.Error = &rpcError{Code: -32000, Message: err.Error()}
+		} else {
+			resp.Result = result
+		}
+
+		encMu.Lock()
+		err = enc.Encode(resp)
+		encMu.Unlock()
+		if err != nil {
+			return fmt.Errorf("error writing response: %w", err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,225 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cli/cli/v2/pkg/liveshare"
+	sshproxy "github.com/cli/cli/v2/pkg/liveshare/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+type notifyFunc func(method string, params interface{})
+
+// dispatch decodes params for method and invokes the matching
+// handler, returning the value to place in the JSON-RPC response's
+// result field.
+func (s *Server) dispatch(ctx context.Context, method string, params json.RawMessage, notify notifyFunc) (interface{}, error) {
+	switch method {
+	case "portForward":
+		var args struct {
+			RemotePort int `json:"remotePort"`
+			LocalPort  int `json:"localPort"`
+		}
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, err
+		}
+		return s.portForward(ctx, args.RemotePort, args.LocalPort)
+
+	case "listPorts":
+		return s.listPorts()
+
+	case "updateSharedVisibility":
+		var args struct {
+			Port   int  `json:"port"`
+			Public bool `json:"public"`
+		}
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.session.UpdateSharedVisibility(ctx, args.Port, args.Public)
+
+	case "startSSHServer":
+		return s.startSSHServer(ctx)
+
+	case "execCommand":
+		var args struct {
+			Port int      `json:"port"`
+			Argv []string `json:"argv"`
+			Env  []string `json:"env"`
+			TTY  bool     `json:"tty"`
+		}
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, err
+		}
+		return s.execCommand(ctx, args.Port, args.Argv, args.Env, args.TTY, notify)
+
+	case "rebuildContainer":
+		var args struct {
+			Full bool `json:"full"`
+		}
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.session.RebuildContainer(ctx, args.Full)
+
+	case "keepAlive":
+		var args struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, err
+		}
+		s.session.KeepAlive(args.Reason)
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown method %q", method)
+	}
+}
+
+// portForward binds localPort (0 picks a free port) and starts
+// forwarding it to remotePort on the remote side, returning the bound
+// local address.
+func (s *Server) portForward(ctx context.Context, remotePort, localPort int) (string, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+	if err != nil {
+		return "", fmt.Errorf("error listening on local port: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listeners[remotePort] = ln
+	s.mu.Unlock()
+
+	fwd := liveshare.NewPortForwarder(s.session, fmt.Sprintf("ipc-%d", remotePort), remotePort, true)
+	go fwd.ForwardToListener(ctx, ln) // nolint:errcheck
+
+	return ln.Addr().String(), nil
+}
+
+type portInfo struct {
+	RemotePort int  `json:"remotePort"`
+	Forwarded  bool `json:"forwarded"`
+}
+
+// listPorts reports which remote ports currently have an active
+// portForward bound to them.
+func (s *Server) listPorts() ([]portInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ports := make([]portInfo, 0, len(s.listeners))
+	for port := range s.listeners {
+		ports = append(ports, portInfo{RemotePort: port, Forwarded: true})
+	}
+	return ports, nil
+}
+
+// startSSHServer asks the remote host to start an SSH server, then
+// starts a local proxying ssh.Server in front of it and returns the
+// local address callers should point an ssh client at, together with
+// the remote sshd's port, which execCommand takes as its port.
+func (s *Server) startSSHServer(ctx context.Context) (interface{}, error) {
+	remotePort, err := s.session.StartSSHServer(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("startSSHServer: %w", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("startSSHServer: error listening for clients: %w", err)
+	}
+
+	srv := sshproxy.NewServer(s.session, remotePort)
+	go srv.Serve(ctx, ln) // nolint:errcheck
+
+	return struct {
+		LocalAddr  string `json:"localAddr"`
+		RemotePort int    `json:"remotePort"`
+	}{LocalAddr: ln.Addr().String(), RemotePort: remotePort}, nil
+}
+
+// execCommand runs argv on the remote host over a new SSH session
+// dialed through port (the remote sshd started by startSSHServer),
+// streaming its output as notifications rather than buffering it, so
+// the caller can render it live. The notifications are $/exec/start,
+// $/exec/stdout, $/exec/stderr and $/exec/exit; there is no single
+// $/notify method.
+func (s *Server) execCommand(ctx context.Context, port int, argv, env []string, tty bool, notify notifyFunc) (interface{}, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("execCommand: argv must not be empty")
+	}
+
+	client, err := s.session.DialRemoteSSH(ctx, port)
+	if err != nil {
+		return nil, fmt.Errorf("execCommand: error connecting to remote host: %w", err)
+	}
+	defer client.Close()
+
+	remote, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("execCommand: error opening remote session: %w", err)
+	}
+	defer remote.Close()
+
+	for _, kv := range env {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		remote.Setenv(k, v) // nolint:errcheck
+	}
+
+	if tty {
+		if err := remote.RequestPty("xterm", 80, 24, gossh.TerminalModes{}); err != nil {
+			return nil, fmt.Errorf("execCommand: error requesting pty: %w", err)
+		}
+	}
+
+	stdout, err := remote.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("execCommand: error attaching stdout: %w", err)
+	}
+	stderr, err := remote.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("execCommand: error attaching stderr: %w", err)
+	}
+
+	notify("exec/start", map[string]interface{}{"argv": argv})
+
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			notify("exec/stderr", map[string]interface{}{"data": scanner.Text()})
+		}
+	}()
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			notify("exec/stdout", map[string]interface{}{"data": scanner.Text()})
+		}
+	}()
+
+	runErr := remote.Run(strings.Join(argv, " "))
+	<-stderrDone
+
+	exitCode := 0
+	if runErr != nil {
+		exitErr, ok := runErr.(*gossh.ExitError)
+		if !ok {
+			return nil, fmt.Errorf("execCommand: %w", runErr)
+		}
+		exitCode = exitErr.ExitStatus()
+	}
+	notify("exec/exit", map[string]interface{}{"exitCode": exitCode})
+
+	return struct {
+		ExitCode int `json:"exitCode"`
+	}{ExitCode: exitCode}, nil
+}
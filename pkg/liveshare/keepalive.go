@@ -0,0 +1,12 @@
+package liveshare
+
+// KeepAlive sends reason on the session's keep-alive channel so that
+// heartbeat pings the host right away, tagged with reason. It is safe to call from any
+// goroutine; if the channel is full the reason is dropped, since
+// heartbeat only ever needs to observe that some activity occurred.
+func (s *Session) KeepAlive(reason string) {
+	select {
+	case s.keepAliveReason <- reason:
+	default:
+	}
+}
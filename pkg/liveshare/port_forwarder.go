@@ -0,0 +1,119 @@
+package liveshare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+// A PortForwarder forwards connections between a local endpoint and a
+// single port exposed by the remote host, over a channel identified
+// by sessionName.
+type PortForwarder struct {
+	session                     *Session
+	sessionName                 string
+	port                        int
+	keepAliveWithNewConnections bool
+}
+
+// NewPortForwarder returns a PortForwarder that forwards connections
+// to port on the remote host, identifying the forwarded channel as
+// sessionName. When keepAliveWithNewConnections is true, each new
+// connection bumps the session's keep-alive.
+func NewPortForwarder(session *Session, sessionName string, port int, keepAliveWithNewConnections bool) *PortForwarder {
+	return &PortForwarder{
+		session:                     session,
+		sessionName:                 sessionName,
+		port:                        port,
+		keepAliveWithNewConnections: keepAliveWithNewConnections,
+	}
+}
+
+// ForwardToListener accepts connections on ln until ctx is canceled or
+// Accept returns a non-nil error, forwarding each one to the
+// forwarder's remote port.
+func (fwd *PortForwarder) ForwardToListener(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				return fmt.Errorf("error accepting connection: %w", err)
+			}
+		}
+		go func() {
+			defer conn.Close()
+			if err := fwd.Forward(ctx, conn); err != nil && fwd.session.logger != nil {
+				fwd.session.logger.Printf("error forwarding connection: %v", err)
+			}
+		}()
+	}
+}
+
+// Forward forwards rw to the forwarder's remote port until rw or the
+// remote channel is closed. The connection is counted against the
+// session's Stats for the duration of the call, tagged with the
+// session's SessionType.
+func (fwd *PortForwarder) Forward(ctx context.Context, rw io.ReadWriteCloser) error {
+	if fwd.keepAliveWithNewConnections {
+		fwd.session.KeepAlive(fmt.Sprintf("%s: new connection", fwd.sessionName))
+	}
+
+	channel, err := fwd.session.openChannel(ctx, fwd.sessionName, fwd.port)
+	if err != nil {
+		return fmt.Errorf("error opening channel for %s: %w", fwd.sessionName, err)
+	}
+	defer channel.Close()
+
+	sessionType := fwd.session.sessionType
+	fwd.session.recordConnectionOpened(sessionType)
+	n, err := copyBoth(ctx, channel, rw)
+	fwd.session.recordConnectionClosed(sessionType, n)
+	return err
+}
+
+// copyBoth copies data in both directions between a and b until ctx
+// is canceled or either copy finishes, then closes both ends to
+// unblock whichever copy is still running and waits for it too,
+// before reporting the total bytes moved in both directions combined.
+// Waiting for both matters: counting as soon as either side finishes
+// would undercount (and report the connection closed) while the other
+// direction, which finishes later in the common case, is still
+// flowing.
+func copyBoth(ctx context.Context, a, b io.ReadWriteCloser) (n int64, err error) {
+	errc := make(chan error, 2)
+	go func() {
+		m, err := io.Copy(a, b)
+		atomic.AddInt64(&n, m)
+		errc <- err
+	}()
+	go func() {
+		m, err := io.Copy(b, a)
+		atomic.AddInt64(&n, m)
+		errc <- err
+	}()
+
+	running := 2
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case err = <-errc:
+		running--
+	}
+
+	a.Close()
+	b.Close()
+	for ; running > 0; running-- {
+		<-errc
+	}
+
+	return atomic.LoadInt64(&n), err
+}
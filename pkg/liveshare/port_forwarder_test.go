@@ -0,0 +1,61 @@
+package liveshare
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeRWC is an io.ReadWriteCloser backed by a fixed Reader and a
+// Writer that accumulates what's written to it, used to drive
+// copyBoth without a real Session or network connection.
+type fakeRWC struct {
+	r io.Reader
+
+	mu     sync.Mutex
+	w      bytes.Buffer
+	closed bool
+}
+
+func (f *fakeRWC) Read(p []byte) (int, error) { return f.r.Read(p) }
+
+func (f *fakeRWC) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.w.Write(p)
+}
+
+func (f *fakeRWC) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	return nil
+}
+
+func TestCopyBothCountsBothDirections(t *testing.T) {
+	// One side has far less to send than the other, so its io.Copy
+	// finishes first; copyBoth must still wait for the larger
+	// direction and include its bytes in the total rather than
+	// returning as soon as the small side hits EOF.
+	small := &fakeRWC{r: bytes.NewReader(bytes.Repeat([]byte("a"), 16))}
+	large := &fakeRWC{r: bytes.NewReader(bytes.Repeat([]byte("b"), 64*1024))}
+
+	n, err := copyBoth(context.Background(), small, large)
+	if err != nil {
+		t.Fatalf("copyBoth: %v", err)
+	}
+
+	want := int64(16 + 64*1024)
+	if n != want {
+		t.Errorf("copyBoth returned n = %d, want %d", n, want)
+	}
+
+	if large.w.Len() != 16 {
+		t.Errorf("large.w.Len() = %d, want 16", large.w.Len())
+	}
+	if small.w.Len() != 64*1024 {
+		t.Errorf("small.w.Len() = %d, want %d", small.w.Len(), 64*1024)
+	}
+}
@@ -0,0 +1,197 @@
+package liveshare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// SessionState describes the current connectivity state of a Session
+// created with ConnectResilient.
+type SessionState int
+
+const (
+	// StateConnected means the session's websocket, SSH, and gRPC
+	// layers are all up and usable.
+	StateConnected SessionState = iota
+	// StateReconnecting means a previous connection was lost and a
+	// new one is being established.
+	StateReconnecting
+	// StateFailed means reconnection was abandoned, either because
+	// MaxRetryDuration elapsed or a fatal error was classified.
+	StateFailed
+)
+
+const (
+	reconnectInitialBackoff = 50 * time.Millisecond
+	reconnectMaxBackoff     = 10 * time.Second
+)
+
+// fatalError wraps an error that reconnection should not retry, such
+// as an authorization failure.
+type fatalError struct{ err error }
+
+func (f *fatalError) Error() string { return f.err.Error() }
+func (f *fatalError) Unwrap() error { return f.err }
+
+// Fatal wraps err so that ConnectResilient's supervisor treats it as
+// unrecoverable and stops retrying instead of backing off forever.
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fatalError{err}
+}
+
+func isFatal(err error) bool {
+	var f *fatalError
+	return errors.As(err, &f)
+}
+
+// ConnectResilient behaves like Connect, but wraps the returned
+// Session in a supervisor that transparently reconnects when the
+// session's heartbeat RPC fails. On disconnect it repeats Connect's
+// connection steps (without starting a second heartbeat), re-issuing
+// workspace.joinWorkspace and re-establishing the internal gRPC
+// tunnel, then swaps the new transport into the existing Session
+// so that outstanding PortForwarders (which always dial through the
+// Session rather than caching a transport of their own) pick it up on
+// their next forwarded connection.
+//
+// Retries use exponential backoff from 50ms up to a 10s cap, with
+// jitter, bounded by opts.MaxRetryDuration (zero means no bound).
+// A disconnect or reconnect error wrapped with Fatal stops the
+// supervisor instead of retrying. The connection steps themselves wrap
+// no error with Fatal, so an authorization failure is retried like any
+// other error until MaxRetryDuration elapses. Callers can
+// watch reconnection progress via the returned Session's State
+// channel.
+func ConnectResilient(ctx context.Context, opts Options) (*Session, error) {
+	s, err := Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.enableResilience()
+	go s.superviseReconnect(ctx, opts)
+	return s, nil
+}
+
+// State returns a channel on which the session publishes
+// StateConnected, StateReconnecting, and StateFailed as its
+// connectivity changes. It is nil unless the session was created with
+// ConnectResilient. The channel is never closed.
+func (s *Session) State() <-chan SessionState {
+	_, state, _ := s.resilience()
+	return state
+}
+
+// publishState pushes state onto ch, a session's State() channel,
+// dropping a stale pending value rather than blocking if a reader
+// hasn't drained the last one.
+func publishState(ch chan SessionState, state SessionState) {
+	select {
+	case ch <- state:
+	default:
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- state
+	}
+}
+
+// superviseReconnect rebuilds the session's transport with backoff
+// whenever heartbeat reports a disconnect, until ctx is canceled, a
+// fatal error is classified, or opts.MaxRetryDuration elapses. It
+// reads the state and disconnected channels once, up front: they're
+// installed by enableResilience before this goroutine is started and
+// never change afterwards, so capturing local copies here avoids
+// re-locking s.mu on every iteration while still being safe with
+// respect to the concurrently running heartbeat goroutine.
+func (s *Session) superviseReconnect(ctx context.Context, opts Options) {
+	_, state, disconnected := s.resilience()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-disconnected:
+			if isFatal(err) {
+				publishState(state, StateFailed)
+				return
+			}
+		}
+
+		// MaxRetryDuration bounds each outage, counted from its start.
+		var deadline time.Time
+		if opts.MaxRetryDuration > 0 {
+			deadline = time.Now().Add(opts.MaxRetryDuration)
+		}
+
+		publishState(state, StateReconnecting)
+		backoff := reconnectInitialBackoff
+		for {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				publishState(state, StateFailed)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(jitter(backoff)):
+			}
+
+			if err := s.reconnect(ctx, opts); err != nil {
+				if isFatal(err) {
+					publishState(state, StateFailed)
+					return
+				}
+				backoff *= 2
+				if backoff > reconnectMaxBackoff {
+					backoff = reconnectMaxBackoff
+				}
+				continue
+			}
+			publishState(state, StateConnected)
+			break
+		}
+	}
+}
+
+// jitter returns d randomized uniformly in [d/2, d], so that many
+// reconnecting clients don't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// reconnect rebuilds the websocket, SSH, RPC, and gRPC layers and
+// swaps them into place under s.mu, closing the transport they
+// replace so its websocket/SSH connection and the old gRPC client
+// don't leak. It deliberately calls the unexported connect (not the
+// public Connect) so the rebuild doesn't start a second heartbeat
+// goroutine that would keep running forever on a *Session nothing
+// still references once its fields have been copied out.
+func (s *Session) reconnect(ctx context.Context, opts Options) error {
+	fresh, err := connect(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fresh.connectToGrpcServer(ctx, opts.SessionToken); err != nil {
+		fresh.Close() // nolint:errcheck
+		return fmt.Errorf("error connecting to internal server: %w", err)
+	}
+
+	s.mu.Lock()
+	oldSSH, oldGrpc := s.ssh, s.grpc
+	s.ssh = fresh.ssh
+	s.rpc = fresh.rpc
+	s.grpc = fresh.grpc
+	s.mu.Unlock()
+
+	oldGrpc.Close()
+	oldSSH.Close() // nolint:errcheck
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package liveshare
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFatal(t *testing.T) {
+	if Fatal(nil) != nil {
+		t.Errorf("Fatal(nil) = %v, want nil", Fatal(nil))
+	}
+
+	base := errors.New("boom")
+	wrapped := Fatal(base)
+	if !isFatal(wrapped) {
+		t.Errorf("isFatal(Fatal(err)) = false, want true")
+	}
+	if !errors.Is(wrapped, base) {
+		t.Errorf("Fatal(err) does not unwrap to the original error")
+	}
+	if isFatal(base) {
+		t.Errorf("isFatal(err) = true for a plain error, want false")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	d := 100 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		got := jitter(d)
+		if got < d/2 || got > d {
+			t.Fatalf("jitter(%s) = %s, want value in [%s, %s]", d, got, d/2, d)
+		}
+	}
+}
+
+// TestPublishStateDropsStale asserts that publishState never blocks:
+// a state nobody has read yet is replaced by the next one rather than
+// queued, so superviseReconnect can always report its latest state
+// without waiting on a slow or absent reader.
+func TestPublishStateDropsStale(t *testing.T) {
+	ch := make(chan SessionState, 1)
+
+	done := make(chan struct{})
+	go func() {
+		publishState(ch, StateReconnecting)
+		publishState(ch, StateConnected)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishState blocked instead of dropping the stale value")
+	}
+
+	select {
+	case got := <-ch:
+		if got != StateConnected {
+			t.Errorf("ch received %v, want %v", got, StateConnected)
+		}
+	default:
+		t.Fatal("ch had no value to read")
+	}
+}
@@ -0,0 +1,74 @@
+package liveshare
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// DialRemoteSSH opens a new SSH client connection to the sshd
+// listening on port inside the codespace, tunneled through the
+// session's existing port-forwarding channel rather than any local
+// network path. That sshd is bound to loopback and reachable only
+// through the tunnel, so it accepts the connection without further
+// authentication.
+func (s *Session) DialRemoteSSH(ctx context.Context, port int) (*gossh.Client, error) {
+	local, remote := net.Pipe()
+	fwd := NewPortForwarder(s, "ssh", port, false)
+	go func() {
+		fwd.Forward(ctx, remote) // nolint:errcheck
+		remote.Close()
+	}()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	conn, chans, reqs, err := gossh.NewClientConn(local, addr, &gossh.ClientConfig{
+		User:            "codespace",
+		Auth:            []gossh.AuthMethod{gossh.Password("")},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error dialing remote ssh server: %w", err)
+	}
+	return gossh.NewClient(conn, chans, reqs), nil
+}
+
+// StartSSHServer asks the remote host to start an SSH server and
+// returns the port it's listening on, so callers can reach it through
+// DialRemoteSSH.
+func (s *Session) StartSSHServer(ctx context.Context) (int, error) {
+	_, rpc, _ := s.transport()
+
+	var result struct {
+		Result     bool   `json:"result"`
+		ServerPort string `json:"serverPort"`
+	}
+	if err := rpc.do(ctx, "ISshServerHostService.startRemoteServer", []string{}, &result); err != nil {
+		return 0, fmt.Errorf("error starting remote ssh server: %w", err)
+	}
+	if !result.Result {
+		return 0, fmt.Errorf("remote host declined to start an ssh server")
+	}
+	port, err := strconv.Atoi(result.ServerPort)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing remote ssh server port %q: %w", result.ServerPort, err)
+	}
+	return port, nil
+}
+
+// RebuildContainer asks the remote host to rebuild the codespace's
+// dev container. The call returns once the rebuild has been
+// requested; it does not wait for the rebuild to finish.
+func (s *Session) RebuildContainer(ctx context.Context, full bool) error {
+	_, rpc, _ := s.transport()
+
+	args := struct {
+		Full bool `json:"full"`
+	}{Full: full}
+	if err := rpc.do(ctx, "workspace.rebuildContainer", &args, nil); err != nil {
+		return fmt.Errorf("error rebuilding container: %w", err)
+	}
+	return nil
+}
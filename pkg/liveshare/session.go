@@ -0,0 +1,147 @@
+package liveshare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cli/cli/v2/internal/codespaces/grpc"
+)
+
+// A Session is a connection to a Live Share workspace, established by
+// Connect. It owns the underlying websocket, SSH, and gRPC layers,
+// and is safe for concurrent use by multiple goroutines.
+type Session struct {
+	ssh             *sshSession
+	rpc             *rpcClient
+	grpc            *grpc.Client
+	clientName      string
+	sessionType     string
+	keepAliveReason chan string
+	logger          logger
+
+	mu            sync.Mutex
+	stats         stats
+	autoReconnect bool
+	state         chan SessionState
+	disconnected  chan error
+
+	relay    bool      // whether the tunnel is routed over the Azure Relay hop
+	lastPing time.Time // set by Ping; lets heartbeat skip redundant pings
+}
+
+// transport returns the session's current SSH, RPC, and gRPC layers.
+// Resilient sessions (see ConnectResilient) swap these out from under
+// a running Session on reconnect, so every access goes through here
+// rather than reading the fields directly.
+func (s *Session) transport() (*sshSession, *rpcClient, *grpc.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ssh, s.rpc, s.grpc
+}
+
+// enableResilience turns on auto-reconnect and installs the state and
+// disconnected channels ConnectResilient's supervisor uses, guarded by
+// s.mu since the session's heartbeat goroutine is already running and
+// reads these same fields concurrently.
+func (s *Session) enableResilience() (state chan SessionState, disconnected chan error) {
+	state = make(chan SessionState, 1)
+	disconnected = make(chan error, 1)
+
+	s.mu.Lock()
+	s.autoReconnect = true
+	s.state = state
+	s.disconnected = disconnected
+	s.mu.Unlock()
+
+	return state, disconnected
+}
+
+// resilience returns the fields that control whether and how the
+// session reports disconnects to ConnectResilient's supervisor,
+// guarded the same way transport() guards ssh/rpc/grpc.
+func (s *Session) resilience() (autoReconnect bool, state chan SessionState, disconnected chan error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.autoReconnect, s.state, s.disconnected
+}
+
+// Close terminates the session, tearing down its gRPC, SSH, and
+// websocket layers in turn.
+func (s *Session) Close() error {
+	ssh, _, grpcClient := s.transport()
+	grpcClient.Close()
+	return ssh.Close()
+}
+
+// heartbeat periodically notifies the Live Share host that the
+// session is still in use, so it doesn't reclaim the codespace for
+// inactivity. It runs until ctx is canceled.
+func (s *Session) heartbeat(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	reason := "heartbeat"
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case reason = <-s.keepAliveReason:
+		case <-t.C:
+			if s.sinceLastPing() < interval {
+				// A Speedtest/Ping probe already confirmed the
+				// session is alive more recently than our own
+				// interval would; skip this tick.
+				continue
+			}
+		}
+		_, rpc, _ := s.transport()
+		if err := rpc.do(ctx, "ping", reason, nil); err != nil {
+			if s.logger != nil {
+				s.logger.Printf("error sending heartbeat: %v", err)
+			}
+			if autoReconnect, _, disconnected := s.resilience(); autoReconnect {
+				// Non-blocking: once superviseReconnect gives up and
+				// stops draining disconnected, nothing will read this
+				// channel again, and a blocking send here would wedge
+				// the heartbeat goroutine forever on the next failed
+				// tick.
+				select {
+				case disconnected <- err:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// openChannel dials port on the remote host over the session's
+// existing SSH tunnel. sessionName is used only to label the error
+// returned when the dial fails.
+func (s *Session) openChannel(ctx context.Context, sessionName string, port int) (io.ReadWriteCloser, error) {
+	ssh, _, _ := s.transport()
+	conn, err := ssh.Dial(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("error dialing %s on remote port %d: %w", sessionName, port, err)
+	}
+	return conn, nil
+}
+
+// markPinged records that the session's liveness was just confirmed
+// by a Ping or Speedtest probe, so heartbeat can skip a redundant tick.
+func (s *Session) markPinged(at time.Time) {
+	s.mu.Lock()
+	s.lastPing = at
+	s.mu.Unlock()
+}
+
+// sinceLastPing returns how long it's been since markPinged was last
+// called.
+func (s *Session) sinceLastPing() time.Duration {
+	s.mu.Lock()
+	last := s.lastPing
+	s.mu.Unlock()
+	return time.Since(last)
+}
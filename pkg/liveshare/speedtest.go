@@ -0,0 +1,109 @@
+package liveshare
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// viaRelay reports whether relayEndpoint routes through the Azure
+// Relay hop (as opposed to a direct connection), inferred from its
+// URI scheme/host.
+func viaRelay(relayEndpoint string) bool {
+	u, err := url.Parse(relayEndpoint)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "sb", "wss":
+		return true
+	}
+	return strings.HasSuffix(u.Hostname(), ".servicebus.windows.net")
+}
+
+type pingResult struct {
+	SentAt int64 `json:"sentAt"`
+}
+
+// Ping measures the round-trip time of a single "ping" RPC call and
+// reports whether the tunnel appears to be routed over the Azure
+// Relay hop rather than a direct path.
+func (s *Session) Ping(ctx context.Context) (rtt time.Duration, viaRelayHop bool, err error) {
+	start := time.Now()
+	_, rpc, _ := s.transport()
+	if err := rpc.do(ctx, "ping", pingResult{SentAt: start.UnixNano()}, nil); err != nil {
+		return 0, false, fmt.Errorf("error pinging session: %w", err)
+	}
+	s.markPinged(time.Now())
+	return time.Since(start), s.relay, nil
+}
+
+// SpeedtestResult summarizes a throughput probe. ThroughputMbps is a
+// single combined figure covering both directions of each round trip
+// — see Speedtest's doc comment for why it isn't split into separate
+// upload/download numbers.
+type SpeedtestResult struct {
+	ThroughputMbps float64
+	JitterMs       float64
+}
+
+// speedtestFrame is the size of each payload pushed during Speedtest;
+// large enough to amortize RPC overhead, small enough to keep jitter
+// measurements meaningful.
+const speedtestFrame = 64 * 1024
+
+// Speedtest measures throughput over the session's SSH channel for
+// approximately dur, by pushing framed random payloads to the remote
+// side's echo method and timing the round trips. Because each round
+// trip is a single request/response pair rather than independent
+// one-way streams, there's no way to attribute the RTT to upload vs.
+// download separately; ThroughputMbps instead reports the combined
+// bidirectional rate (both directions' bytes, divided by RTT), which
+// is still useful for spotting VPN/relay/workspace-side slowness even
+// though it can't tell the two directions apart.
+func (s *Session) Speedtest(ctx context.Context, dur time.Duration) (SpeedtestResult, error) {
+	deadline := time.Now().Add(dur)
+	payload := make([]byte, speedtestFrame)
+	if _, err := rand.Read(payload); err != nil {
+		return SpeedtestResult{}, fmt.Errorf("error generating speedtest payload: %w", err)
+	}
+
+	var (
+		rounds         int
+		totalRTT       time.Duration
+		minRTT, maxRTT time.Duration
+	)
+	for time.Now().Before(deadline) {
+		start := time.Now()
+		var echoed []byte
+		_, rpc, _ := s.transport()
+		if err := rpc.do(ctx, "speedtest/echo", payload, &echoed); err != nil {
+			return SpeedtestResult{}, fmt.Errorf("error during speedtest round %d: %w", rounds, err)
+		}
+		rtt := time.Since(start)
+		totalRTT += rtt
+		if rounds == 0 || rtt < minRTT {
+			minRTT = rtt
+		}
+		if rtt > maxRTT {
+			maxRTT = rtt
+		}
+		rounds++
+	}
+	if rounds == 0 {
+		return SpeedtestResult{}, fmt.Errorf("speedtest: no rounds completed in %s", dur)
+	}
+	s.markPinged(time.Now())
+
+	bytesPerRound := float64(len(payload) * 2) // up + down
+	avgRTT := totalRTT / time.Duration(rounds)
+	mbps := (bytesPerRound * 8 / 1e6) / avgRTT.Seconds()
+
+	return SpeedtestResult{
+		ThroughputMbps: mbps,
+		JitterMs:       float64(maxRTT-minRTT) / float64(time.Millisecond),
+	}, nil
+}
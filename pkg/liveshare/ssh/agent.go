@@ -0,0 +1,35 @@
+package ssh
+
+import (
+	"net"
+
+	gliderssh "github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// enableAgentForwarding lets a session borrow the client's forwarded
+// ssh-agent, if one was requested, and relays it on to remote so that
+// commands run inside the codespace (e.g. git over SSH) can use the
+// user's local keys. The listener it creates stays open until sess
+// ends; callers must not treat this as blocking.
+func enableAgentForwarding(sess gliderssh.Session, remote *gossh.Client) {
+	if !gliderssh.AgentRequested(sess) {
+		return
+	}
+	l, err := gliderssh.NewAgentListener()
+	if err != nil {
+		return
+	}
+	go func() {
+		<-sess.Context().Done()
+		l.Close()
+	}()
+	go gliderssh.ForwardAgentConnections(l, sess)
+
+	conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		return
+	}
+	agent.ForwardToAgent(remote, agent.NewClient(conn)) // nolint:errcheck
+}
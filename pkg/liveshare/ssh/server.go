@@ -0,0 +1,292 @@
+// Package ssh implements a local SSH server that sits in front of a
+// Live Share session's forwarded port, so editors and other tools can
+// attach to a codespace the same way they would to any other SSH host
+// instead of shelling out to the system ssh client. Every channel it
+// serves — interactive sessions, the SFTP subsystem, agent forwarding,
+// and direct-tcpip forwarding — is proxied through to the remote host
+// over the session's existing tunnel; nothing is executed locally.
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/cli/cli/v2/pkg/liveshare"
+	gliderssh "github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// A Server accepts SSH connections on a local listener and serves them
+// by proxying the underlying channels through a Live Share Session's
+// port-forwarding tunnel to the sshd listening on remotePort inside
+// the codespace, so callers don't have to shell out to a system ssh
+// binary.
+type Server struct {
+	session    *liveshare.Session
+	remotePort int
+	inner      *gliderssh.Server
+
+	mu      sync.Mutex
+	clients map[string]*gossh.Client // remote client per incoming connection, keyed by ctx.SessionID()
+}
+
+// NewServer returns a Server that multiplexes client connections
+// through session, forwarding to remotePort on the remote side.
+func NewServer(session *liveshare.Session, remotePort int) *Server {
+	s := &Server{
+		session:    session,
+		remotePort: remotePort,
+		clients:    make(map[string]*gossh.Client),
+	}
+	s.inner = &gliderssh.Server{
+		Handler:     s.handleSession,
+		PtyCallback: func(ctx gliderssh.Context, pty gliderssh.Pty) bool { return true },
+		SubsystemHandlers: map[string]gliderssh.SubsystemHandler{
+			"sftp": s.handleSFTP,
+		},
+		ChannelHandlers: map[string]gliderssh.ChannelHandler{
+			"session":      gliderssh.DefaultSessionHandler,
+			"direct-tcpip": s.handleDirectTCPIP,
+		},
+		RequestHandlers: map[string]gliderssh.RequestHandler{
+			"*": gliderssh.DefaultRequestHandler,
+		},
+	}
+	return s
+}
+
+// Serve accepts connections on ln until ctx is canceled or Serve
+// returns a non-nil error. Each accepted connection bumps the
+// session's keep-alive so the codespace stays warm while a client is
+// attached.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+				return err
+			}
+		}
+		s.session.KeepAlive("ssh-server: new connection")
+		go s.inner.HandleConn(conn)
+	}
+}
+
+// remoteClient returns the single remote SSH client shared by every
+// channel multiplexed over the same incoming connection, dialing it
+// lazily on first use and tearing it down when the connection ends.
+func (s *Server) remoteClient(ctx gliderssh.Context) (*gossh.Client, error) {
+	id := ctx.SessionID()
+
+	s.mu.Lock()
+	client, ok := s.clients[id]
+	s.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	client, err := s.session.DialRemoteSSH(ctx, s.remotePort)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if existing, ok := s.clients[id]; ok {
+		// Another channel on this connection dialed first; share its client.
+		s.mu.Unlock()
+		client.Close()
+		return existing, nil
+	}
+	s.clients[id] = client
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.clients, id)
+		s.mu.Unlock()
+		client.Close()
+	}()
+
+	return client, nil
+}
+
+// handleSession proxies an interactive (PTY or exec) SSH session
+// through to a shell or command run on the remote host, rather than
+// running it on the machine hosting this server.
+func (s *Server) handleSession(sess gliderssh.Session) {
+	client, err := s.remoteClient(sess.Context())
+	if err != nil {
+		fmt.Fprintf(sess, "liveshare ssh: error connecting to remote host: %v\n", err)
+		sess.Exit(1)
+		return
+	}
+	enableAgentForwarding(sess, client)
+
+	remote, err := client.NewSession()
+	if err != nil {
+		fmt.Fprintf(sess, "liveshare ssh: error opening remote session: %v\n", err)
+		sess.Exit(1)
+		return
+	}
+	defer remote.Close()
+
+	if gliderssh.AgentRequested(sess) {
+		agent.RequestAgentForwarding(remote) // nolint:errcheck
+	}
+
+	if ptyReq, winCh, isPty := sess.Pty(); isPty {
+		if err := remote.RequestPty(ptyReq.Term, ptyReq.Window.Height, ptyReq.Window.Width, gossh.TerminalModes{}); err != nil {
+			fmt.Fprintf(sess, "liveshare ssh: error requesting remote pty: %v\n", err)
+			sess.Exit(1)
+			return
+		}
+		go func() {
+			for win := range winCh {
+				remote.WindowChange(win.Height, win.Width) // nolint:errcheck
+			}
+		}()
+	}
+
+	remote.Stdout = sess
+	remote.Stderr = sess.Stderr()
+	stdin, err := remote.StdinPipe()
+	if err != nil {
+		fmt.Fprintf(sess, "liveshare ssh: error attaching stdin: %v\n", err)
+		sess.Exit(1)
+		return
+	}
+	go func() {
+		io.Copy(stdin, sess) // nolint:errcheck
+		stdin.Close()
+	}()
+
+	var runErr error
+	if cmd := strings.Join(sess.Command(), " "); cmd != "" {
+		runErr = remote.Run(cmd)
+	} else if runErr = remote.Shell(); runErr == nil {
+		runErr = remote.Wait()
+	}
+
+	sess.Exit(exitStatus(runErr)) // nolint:errcheck
+}
+
+// exitStatus extracts the remote command's exit code from err, the
+// way a local shell would report it.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*gossh.ExitError); ok {
+		return exitErr.ExitStatus()
+	}
+	return 1
+}
+
+// handleSFTP proxies the SFTP subsystem through to the sftp-server
+// subsystem on the remote host, so file operations act on the
+// codespace's filesystem rather than the local one.
+func (s *Server) handleSFTP(sess gliderssh.Session) {
+	client, err := s.remoteClient(sess.Context())
+	if err != nil {
+		fmt.Fprintf(sess, "liveshare ssh: error connecting to remote host: %v\n", err)
+		sess.Exit(1)
+		return
+	}
+
+	remote, err := client.NewSession()
+	if err != nil {
+		fmt.Fprintf(sess, "liveshare ssh: error opening remote session: %v\n", err)
+		sess.Exit(1)
+		return
+	}
+	defer remote.Close()
+
+	stdin, err := remote.StdinPipe()
+	if err != nil {
+		sess.Exit(1)
+		return
+	}
+	stdout, err := remote.StdoutPipe()
+	if err != nil {
+		sess.Exit(1)
+		return
+	}
+	if err := remote.RequestSubsystem("sftp"); err != nil {
+		fmt.Fprintf(sess, "liveshare ssh: remote sftp subsystem unavailable: %v\n", err)
+		sess.Exit(1)
+		return
+	}
+
+	go func() {
+		io.Copy(stdin, sess) // nolint:errcheck
+		stdin.Close()
+	}()
+	io.Copy(sess, stdout) // nolint:errcheck
+	remote.Wait()         // nolint:errcheck
+}
+
+// directTCPIPData is the payload of a "direct-tcpip" channel open
+// request, as defined by RFC 4254 §7.2.
+type directTCPIPData struct {
+	DestAddr   string
+	DestPort   uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// handleDirectTCPIP implements local ("-L style") TCP forwarding by
+// dialing the requested destination through the remote SSH client
+// (which itself opens a direct-tcpip channel on the codespace), so
+// forwarded connections land on the codespace's network rather than
+// the machine hosting this server.
+func (s *Server) handleDirectTCPIP(srv *gliderssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx gliderssh.Context) {
+	var d directTCPIPData
+	if err := gossh.Unmarshal(newChan.ExtraData(), &d); err != nil {
+		newChan.Reject(gossh.ConnectionFailed, "error parsing forward data: "+err.Error())
+		return
+	}
+
+	client, err := s.remoteClient(ctx)
+	if err != nil {
+		newChan.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+
+	dest := fmt.Sprintf("%s:%d", d.DestAddr, d.DestPort)
+	dconn, err := client.Dial("tcp", dest)
+	if err != nil {
+		newChan.Reject(gossh.ConnectionFailed, err.Error())
+		return
+	}
+
+	ch, reqs, err := newChan.Accept()
+	if err != nil {
+		dconn.Close()
+		return
+	}
+	go gossh.DiscardRequests(reqs)
+
+	go func() {
+		defer ch.Close()
+		defer dconn.Close()
+		io.Copy(ch, dconn) // nolint:errcheck
+	}()
+	go func() {
+		defer ch.Close()
+		defer dconn.Close()
+		io.Copy(dconn, ch) // nolint:errcheck
+	}()
+}
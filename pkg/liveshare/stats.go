@@ -0,0 +1,87 @@
+package liveshare
+
+// Session type constants identify the kind of client attached to a
+// Session. The value set in Options.SessionType is sent to the Live
+// Share host in joinWorkspaceArgs.ClientCapabilities and keys the
+// per-type breakdown returned by Session.Stats.
+const (
+	SessionTypeVSCode         = "vscode"
+	SessionTypeJetBrains      = "jetbrains"
+	SessionTypeSCP            = "scp"
+	SessionTypePortForward    = "port-forward"
+	SessionTypeInteractiveSSH = "ssh"
+)
+
+// Stats is a snapshot of a Session's connection activity, broken down
+// by session type.
+type Stats struct {
+	ActiveConnections int
+	BytesForwarded    int64
+	BySessionType     map[string]*TypeStats
+}
+
+// TypeStats holds the counters tracked for a single session type.
+type TypeStats struct {
+	ActiveConnections int
+	TotalConnections  int
+	BytesForwarded    int64
+}
+
+// stats is the mutable counter state embedded in a Session; callers
+// observe it only through Session.Stats, which returns a snapshot.
+type stats struct {
+	activeConnections int
+	bytesForwarded    int64
+	byType            map[string]*TypeStats
+}
+
+// Stats returns a point-in-time snapshot of the session's connection
+// counters, broken down by session type.
+func (s *Session) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byType := make(map[string]*TypeStats, len(s.stats.byType))
+	for t, c := range s.stats.byType {
+		cc := *c
+		byType[t] = &cc
+	}
+	return Stats{
+		ActiveConnections: s.stats.activeConnections,
+		BytesForwarded:    s.stats.bytesForwarded,
+		BySessionType:     byType,
+	}
+}
+
+// recordConnectionOpened registers a newly accepted connection of the
+// given session type against the session's counters.
+func (s *Session) recordConnectionOpened(sessionType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats.byType == nil {
+		s.stats.byType = make(map[string]*TypeStats)
+	}
+	t, ok := s.stats.byType[sessionType]
+	if !ok {
+		t = &TypeStats{}
+		s.stats.byType[sessionType] = t
+	}
+	s.stats.activeConnections++
+	t.ActiveConnections++
+	t.TotalConnections++
+}
+
+// recordConnectionClosed marks a connection of the given session type
+// as closed, after having forwarded n bytes over its lifetime.
+func (s *Session) recordConnectionClosed(sessionType string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.activeConnections--
+	s.stats.bytesForwarded += n
+	if t, ok := s.stats.byType[sessionType]; ok {
+		t.ActiveConnections--
+		t.BytesForwarded += n
+	}
+}